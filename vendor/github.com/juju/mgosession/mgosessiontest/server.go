@@ -0,0 +1,240 @@
+// Copyright 2016 Canonical Ltd.
+
+// Package mgosessiontest provides an ephemeral mongod instance wired
+// up to an mgosession.Pool, so that downstream projects can test their
+// handlers against a real MongoDB server without pulling in a
+// separate testserver dependency.
+package mgosessiontest
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/juju/mgosession"
+	"github.com/juju/utils/clock"
+	"github.com/juju/utils/clock/testing"
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/tomb.v2"
+)
+
+// dialTimeout bounds how long Start waits for the freshly spawned
+// mongod to accept connections.
+const dialTimeout = 30 * time.Second
+
+// mockClockMu serializes use of Params.MockClock across every Server
+// in the process: mgosession.Clock is a single package-level
+// variable, so two Servers with MockClock set at the same time (for
+// example under t.Parallel) would stomp on each other's fake clock,
+// and one Server's Stop could restore the wrong previous clock under
+// another's feet. New acquires this lock and Stop releases it, so a
+// second MockClock Server simply waits for the first one's Stop
+// instead of racing it.
+//
+// This only serializes MockClock Servers against each other. It does
+// nothing for a pool that reads mgosession.Clock without going
+// through a MockClock Server (e.g. one created directly with
+// NewPoolWithURL): such a pool's pinger will still block on the fake
+// clock's Clock.After for as long as a MockClock Server has it
+// installed, since nothing advances the fake clock on its behalf.
+// Don't mix a MockClock Server with an unrelated real-clock pool in
+// the same process.
+var mockClockMu sync.Mutex
+
+// Params holds the options used to start a Server.
+type Params struct {
+	// MaxSessions is the maximum number of sessions maintained by
+	// the server's Pool. If zero, 1 is used.
+	MaxSessions int
+
+	// MockClock, if true, swaps mgosession.Clock for a fake clock
+	// for the lifetime of the server, so that pinger-driven Reset
+	// paths become deterministic in tests instead of racing against
+	// the real 1s pingInterval. At most one Server with MockClock set
+	// may be active at a time per process; New blocks until any
+	// other MockClock Server's Stop has run. Don't run a MockClock
+	// Server alongside an unrelated pool that relies on the real
+	// mgosession.Clock in the same process: its pinger will stall for
+	// as long as the fake clock is installed.
+	MockClock bool
+}
+
+// Server manages an ephemeral mongod instance and a Pool connected to
+// it.
+type Server struct {
+	tomb tomb.Tomb
+
+	dir  string
+	addr string
+	cmd  *exec.Cmd
+	pool *mgosession.Pool
+
+	// prevClock holds mgosession.Clock as it was before New replaced
+	// it with fakeClock, so that Stop can restore it.
+	prevClock   clock.Clock
+	fakeClock   *testing.Clock
+	mockedClock bool
+}
+
+// New starts an ephemeral mongod listening on a free port on
+// 127.0.0.1, waits for it to accept connections, and returns a Server
+// with a Pool already wired to it. The caller must call Stop when
+// done, typically via defer, so that the child mongod is killed and
+// its temporary dbpath removed even if the test panics.
+func New(logger mgosession.Logger, p Params) (*Server, error) {
+	dir, err := ioutil.TempDir("", "mgosessiontest-")
+	if err != nil {
+		return nil, fmt.Errorf("cannot create temp dbpath: %v", err)
+	}
+	port, err := freePort()
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cannot find a free port: %v", err)
+	}
+	addr := fmt.Sprintf("127.0.0.1:%d", port)
+	// --nojournal, --noprealloc and --smallfiles were MMAPv1-only
+	// flags and no longer exist on any mongod that still ships an
+	// engine (MMAPv1 was removed in 4.2), so they're deliberately not
+	// passed here.
+	cmd := exec.Command("mongod",
+		"--dbpath", dir,
+		"--port", strconv.Itoa(port),
+		"--bind_ip", "127.0.0.1",
+	)
+	if err := cmd.Start(); err != nil {
+		os.RemoveAll(dir)
+		return nil, fmt.Errorf("cannot start mongod: %v", err)
+	}
+	s := &Server{
+		dir:  dir,
+		addr: addr,
+		cmd:  cmd,
+	}
+	s.tomb.Go(func() error {
+		<-s.tomb.Dying()
+		if s.cmd.Process != nil {
+			s.cmd.Process.Kill()
+		}
+		return s.cmd.Wait()
+	})
+
+	if p.MockClock {
+		mockClockMu.Lock()
+		s.mockedClock = true
+		s.prevClock = mgosession.Clock
+		s.fakeClock = testing.NewClock(time.Now())
+	}
+
+	session, err := dialWithRetry(addr)
+	if err != nil {
+		s.Stop()
+		return nil, err
+	}
+	defer session.Close()
+
+	if s.mockedClock {
+		mgosession.Clock = s.fakeClock
+	}
+
+	maxSessions := p.MaxSessions
+	if maxSessions <= 0 {
+		maxSessions = 1
+	}
+	s.pool = mgosession.NewPool(logger, session, maxSessions)
+	return s, nil
+}
+
+// Pool returns the session pool connected to the ephemeral mongod.
+func (s *Server) Pool() *mgosession.Pool {
+	return s.pool
+}
+
+// Addr returns the "host:port" address the ephemeral mongod is
+// listening on, for tests that need to dial it themselves (for
+// example via mgosession.NewPoolWithURL) instead of using Pool.
+func (s *Server) Addr() string {
+	return s.addr
+}
+
+// Clock returns the fake clock installed as mgosession.Clock, or nil
+// if Params.MockClock was false. Advance it to deterministically
+// drive the pool's pinger.
+func (s *Server) Clock() *testing.Clock {
+	return s.fakeClock
+}
+
+// Wipe drops every database except the ones MongoDB itself needs, so
+// that tests start from a clean slate without paying to restart
+// mongod.
+func (s *Server) Wipe() error {
+	h := s.pool.Session(nil)
+	defer s.pool.Release(h)
+	names, err := h.Session.DatabaseNames()
+	if err != nil {
+		return fmt.Errorf("cannot list databases: %v", err)
+	}
+	for _, name := range names {
+		switch name {
+		case "admin", "local", "config":
+			continue
+		}
+		if err := h.Session.DB(name).DropDatabase(); err != nil {
+			return fmt.Errorf("cannot drop database %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// Stop closes the Pool, kills the mongod process, restores
+// mgosession.Clock if it was mocked, and removes the temporary
+// dbpath. It is safe to call more than once.
+func (s *Server) Stop() error {
+	if s.pool != nil {
+		s.pool.Close()
+		s.pool = nil
+	}
+	s.tomb.Kill(nil)
+	err := s.tomb.Wait()
+	if s.mockedClock {
+		mgosession.Clock = s.prevClock
+		s.mockedClock = false
+		mockClockMu.Unlock()
+	}
+	if s.dir != "" {
+		os.RemoveAll(s.dir)
+		s.dir = ""
+	}
+	return err
+}
+
+// freePort asks the kernel for a free open port on 127.0.0.1.
+func freePort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
+	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
+
+// dialWithRetry dials addr, retrying until dialTimeout elapses to
+// give the freshly spawned mongod time to start accepting
+// connections.
+func dialWithRetry(addr string) (*mgo.Session, error) {
+	deadline := time.Now().Add(dialTimeout)
+	var lastErr error
+	for time.Now().Before(deadline) {
+		session, err := mgo.DialWithTimeout(addr, 500*time.Millisecond)
+		if err == nil {
+			return session, nil
+		}
+		lastErr = err
+		time.Sleep(100 * time.Millisecond)
+	}
+	return nil, fmt.Errorf("timed out waiting for mongod at %s to accept connections: %v", addr, lastErr)
+}
@@ -0,0 +1,134 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+const (
+	defaultInitialBackoff = 100 * time.Millisecond
+	defaultMaxBackoff     = time.Minute
+)
+
+// handleFailure is called whenever the pinger or a session acquisition
+// notices that the base session is unusable. For pools that don't own
+// their dial (p.url == "") it falls back to the old Reset behaviour;
+// for pools created with NewPoolWithURL it starts a reconnect loop,
+// unless one is already running.
+func (p *Pool) handleFailure(logger Logger) {
+	if p.url == "" {
+		p.Reset()
+		return
+	}
+	p.mu.Lock()
+	if p.closed || p.reconnecting {
+		p.mu.Unlock()
+		return
+	}
+	p.reconnecting = true
+	// Add must happen in the same critical section as the p.closed
+	// check above, ordered against Close's closed = true write by the
+	// same mutex: otherwise a Session call racing Close could observe
+	// closed == false, unlock, and only call Add after Close's
+	// reconnectWG.Wait has already seen the counter at zero, which is
+	// a WaitGroup misuse (Add concurrent with Wait) that can panic or
+	// let Close tear down sessions out from under a reconnect that's
+	// about to start.
+	p.reconnectWG.Add(1)
+	p.mu.Unlock()
+
+	p.statsMu.Lock()
+	p.stats.Reconnecting = true
+	p.statsMu.Unlock()
+
+	go p.reconnectLoop(logger)
+}
+
+// reconnectLoop repeatedly redials p.url with exponential backoff
+// until it succeeds, the pool is closed, or opts.MaxAttempts is
+// exceeded. On success it drains and closes the old cached sessions
+// and swaps in the new base session under p.mu.
+func (p *Pool) reconnectLoop(logger Logger) {
+	defer p.reconnectWG.Done()
+	defer func() {
+		p.mu.Lock()
+		p.reconnecting = false
+		p.mu.Unlock()
+
+		p.statsMu.Lock()
+		p.stats.Reconnecting = false
+		p.statsMu.Unlock()
+	}()
+
+	initial := p.opts.InitialBackoff
+	if initial <= 0 {
+		initial = defaultInitialBackoff
+	}
+	maxDelay := p.opts.MaxBackoff
+	if maxDelay <= 0 {
+		maxDelay = defaultMaxBackoff
+	}
+	delay := initial
+	attempts := 0
+	for {
+		p.mu.Lock()
+		closed := p.closed
+		p.mu.Unlock()
+		if closed {
+			return
+		}
+
+		attempts++
+		newSession, err := mgo.Dial(p.url)
+		if err == nil {
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				newSession.Close()
+				return
+			}
+			p.closeSessions()
+			p.session.Close()
+			p.session = newSession
+			p.mu.Unlock()
+
+			p.statsMu.Lock()
+			p.stats.Attempts = attempts
+			p.stats.LastError = nil
+			p.stats.LastPing = Clock.Now()
+			p.statsMu.Unlock()
+
+			logger.Info(fmt.Sprintf("reconnected to %s after %d attempt(s)", p.url, attempts))
+			return
+		}
+
+		p.statsMu.Lock()
+		p.stats.Attempts = attempts
+		p.stats.LastError = err
+		p.statsMu.Unlock()
+		logger.Info(fmt.Sprintf("reconnect attempt %d to %s failed: %v", attempts, p.url, err))
+
+		if p.opts.MaxAttempts > 0 && attempts >= p.opts.MaxAttempts {
+			return
+		}
+
+		wait := delay
+		if p.opts.Jitter > 0 {
+			wait += time.Duration(float64(delay) * p.opts.Jitter * rand.Float64())
+		}
+		select {
+		case <-p.tomb.Dying():
+			return
+		case <-Clock.After(wait):
+		}
+		delay *= 2
+		if delay > maxDelay {
+			delay = maxDelay
+		}
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juju/mgosession"
+	"github.com/juju/mgosession/mgosessiontest"
+)
+
+// TestReconnectLoopGivesUpAfterMaxAttempts starts an ephemeral mongod,
+// points a Pool at it with NewPoolWithURL, kills the mongod out from
+// under the pool, and checks that the reconnect loop retries with
+// backoff and eventually reports giving up via Stats, instead of
+// leaving callers stuck with a dead session forever.
+func TestReconnectLoopGivesUpAfterMaxAttempts(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+
+	pool, err := mgosession.NewPoolWithURL(nil, srv.Addr(), mgosession.PoolOptions{
+		MaxSessions:    1,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+		MaxAttempts:    3,
+	})
+	if err != nil {
+		srv.Stop()
+		t.Fatalf("NewPoolWithURL: %v", err)
+	}
+	defer pool.Close()
+
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	// Any acquisition against the now-dead base session should kick
+	// off the reconnect loop.
+	h := pool.Session(nil)
+	pool.Release(h)
+
+	// Each attempt dials a dead address, which mgo.Dial can take a
+	// few seconds to give up on by itself, so allow generously for
+	// three attempts rather than racing the backoff delay.
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		stats := pool.Stats()
+		if !stats.Reconnecting && stats.Attempts >= 3 {
+			if stats.LastError == nil {
+				t.Fatalf("expected Stats.LastError to be set after giving up")
+			}
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("reconnect loop did not give up after MaxAttempts: %+v", pool.Stats())
+}
+
+// TestCloseDuringReconnectDoesNotHang exercises Close racing an
+// in-flight reconnect loop: Close must not block forever, and once it
+// returns the pool must be fully torn down rather than left holding a
+// freshly redialled session that the reconnect loop swapped in after
+// Close started tearing things down.
+func TestCloseDuringReconnectDoesNotHang(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+
+	pool, err := mgosession.NewPoolWithURL(nil, srv.Addr(), mgosession.PoolOptions{
+		MaxSessions:    1,
+		InitialBackoff: time.Millisecond,
+		MaxBackoff:     5 * time.Millisecond,
+	})
+	if err != nil {
+		srv.Stop()
+		t.Fatalf("NewPoolWithURL: %v", err)
+	}
+
+	if err := srv.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	h := pool.Session(nil)
+	pool.Release(h)
+
+	// Give the reconnect loop a moment to start before racing it with
+	// Close.
+	time.Sleep(10 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		pool.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(30 * time.Second):
+		t.Fatalf("Close did not return while a reconnect loop was in flight")
+	}
+}
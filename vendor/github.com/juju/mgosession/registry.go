@@ -0,0 +1,87 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession
+
+import (
+	"fmt"
+	"sync"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// NamedPoolConfig describes one of the pools managed by a Registry.
+type NamedPoolConfig struct {
+	// Name is the logical name the pool is registered under, for
+	// example "tagi", "blick" or "analytics".
+	Name string
+
+	// URL is the MongoDB connection URL the pool dials, as passed to
+	// NewPoolWithURL.
+	URL string
+
+	// MaxSessions is the maximum number of sessions maintained for
+	// reuse by the pool.
+	MaxSessions int
+
+	// Configure, if non-nil, is passed through to the pool's
+	// PoolOptions.Configure.
+	Configure func(*mgo.Session)
+}
+
+// Registry maps logical names to independently-dialled session pools.
+// It centralizes the lifecycle of a process that talks to several
+// independent MongoDB clusters at once.
+type Registry struct {
+	mu    sync.Mutex
+	names []string
+	pools map[string]*Pool
+}
+
+// NewRegistry dials and creates a pool for each entry in configs. If
+// any dial fails, the pools created so far are closed and the error
+// is returned.
+func NewRegistry(logger Logger, configs []NamedPoolConfig) (*Registry, error) {
+	r := &Registry{
+		pools: make(map[string]*Pool, len(configs)),
+	}
+	for _, c := range configs {
+		if _, ok := r.pools[c.Name]; ok {
+			r.Close()
+			return nil, fmt.Errorf("duplicate pool name %q", c.Name)
+		}
+		p, err := NewPoolWithURL(logger, c.URL, PoolOptions{
+			MaxSessions: c.MaxSessions,
+			Configure:   c.Configure,
+		})
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("cannot create pool %q: %v", c.Name, err)
+		}
+		r.names = append(r.names, c.Name)
+		r.pools[c.Name] = p
+	}
+	return r, nil
+}
+
+// Pool returns the pool registered under name, or nil if there is
+// none.
+func (r *Registry) Pool(name string) *Pool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.pools[name]
+}
+
+// Close closes all the pools in the registry, in the reverse of the
+// order they were created in, and may be called concurrently with
+// Pool.
+func (r *Registry) Close() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.names) - 1; i >= 0; i-- {
+		if p := r.pools[r.names[i]]; p != nil {
+			p.Close()
+		}
+	}
+	r.names = nil
+	r.pools = make(map[string]*Pool)
+}
@@ -0,0 +1,89 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession_test
+
+import (
+	"testing"
+
+	mgo "gopkg.in/mgo.v2"
+
+	"github.com/juju/mgosession"
+	"github.com/juju/mgosession/mgosessiontest"
+)
+
+func TestConfigureIsAppliedToEachCopiedSession(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+
+	base := srv.Pool().Session(nil)
+	defer srv.Pool().Release(base)
+
+	var configured int
+	pool := mgosession.NewPoolWithOptions(nil, base.Session, mgosession.PoolOptions{
+		MaxSessions: 1,
+		Configure: func(s *mgo.Session) {
+			configured++
+			s.SetBatch(7)
+		},
+	})
+	defer pool.Close()
+
+	h := pool.Session(nil)
+	pool.Release(h)
+	if configured != 1 {
+		t.Fatalf("expected Configure to run once for the freshly copied session, got %d", configured)
+	}
+
+	// Reusing the same slot must not invoke Configure again.
+	h = pool.Session(nil)
+	pool.Release(h)
+	if configured != 1 {
+		t.Fatalf("expected Configure not to run again for a reused session, got %d", configured)
+	}
+}
+
+func TestRegistryLooksUpAndClosesAllPools(t *testing.T) {
+	srvA, err := mgosessiontest.New(nil, mgosessiontest.Params{})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srvA.Stop()
+	srvB, err := mgosessiontest.New(nil, mgosessiontest.Params{})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srvB.Stop()
+
+	reg, err := mgosession.NewRegistry(nil, []mgosession.NamedPoolConfig{
+		{Name: "a", URL: srvA.Addr(), MaxSessions: 1},
+		{Name: "b", URL: srvB.Addr(), MaxSessions: 1},
+	})
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+
+	poolA := reg.Pool("a")
+	poolB := reg.Pool("b")
+	if poolA == nil || poolB == nil {
+		t.Fatalf("expected both pools to be registered")
+	}
+	if reg.Pool("missing") != nil {
+		t.Fatalf("expected a nil Pool for an unregistered name")
+	}
+
+	reg.Close()
+
+	mustPanic := func(name string, p *mgosession.Pool) {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Fatalf("expected Session on closed pool %q to panic", name)
+			}
+		}()
+		p.Session(nil)
+	}
+	mustPanic("a", poolA)
+	mustPanic("b", poolB)
+}
@@ -19,19 +19,83 @@ const pingInterval = 1 * time.Second
 
 var Clock clock.Clock = clock.WallClock
 
+// AcquireMode determines how Pool.Session hands out sessions when the
+// pool is under contention.
+type AcquireMode int
+
+const (
+	// LeakyMode returns an existing free session when one is available,
+	// and otherwise transparently creates a throwaway session so that
+	// callers are never blocked. This is the pool's traditional
+	// behaviour and is appropriate for read-heavy workloads.
+	LeakyMode AcquireMode = iota
+
+	// CappedMode blocks Session until a session is returned via
+	// Release, guaranteeing that no more than maxSessions sessions
+	// are in use at once. This is appropriate for write-heavy
+	// workloads where Clone's socket sharing is not acceptable.
+	CappedMode
+)
+
+// PoolOptions holds the options used to create a Pool.
+type PoolOptions struct {
+	// MaxSessions holds the maximum number of sessions maintained
+	// for reuse. In CappedMode this is also the maximum number of
+	// sessions that may be handed out concurrently.
+	MaxSessions int
+
+	// Mode determines how Session behaves when the pool is
+	// under contention. The zero value is LeakyMode.
+	Mode AcquireMode
+
+	// Configure, if non-nil, is invoked once on each freshly copied
+	// session before it is stored in the pool (or, for a throwaway
+	// session in LeakyMode, before it is handed to the caller). It
+	// lets callers uniformly set SetMode, SetBatch, SetSocketTimeout,
+	// SetSafe, credentials via DB(x).Login, etc., without wrapping
+	// every Session call site.
+	Configure func(*mgo.Session)
+
+	// The following fields only apply to pools created with
+	// NewPoolWithURL, which own their dial and reconnect themselves
+	// on failure.
+
+	// InitialBackoff holds the delay between the first failed
+	// reconnect attempt and the next one; the first attempt is made
+	// immediately. If zero, a default of 100ms is used.
+	InitialBackoff time.Duration
+
+	// MaxBackoff holds the maximum delay between reconnect attempts.
+	// If zero, a default of 1 minute is used.
+	MaxBackoff time.Duration
+
+	// Jitter holds the fraction of the current backoff delay (0-1)
+	// that is added as random jitter to each reconnect attempt, to
+	// avoid many pools reconnecting in lockstep.
+	Jitter float64
+
+	// MaxAttempts limits the number of reconnect attempts made
+	// before the reconnect loop gives up. Zero means retry forever.
+	MaxAttempts int
+}
+
 // Pool represents a pool of mgo sessions.
 type Pool struct {
 	tomb tomb.Tomb
 
+	opts PoolOptions
+
 	// mu guards the fields below it.
 	mu sync.Mutex
 
 	// sessions holds all the sessions currently available for use.
 	sessions []*mgo.Session
 
-	// sessionIndex holds the index of the next session that will
-	// be returned from Pool.Session.
-	sessionIndex int
+	// freeSlots holds the index of every slot in sessions that is
+	// not currently on loan to a caller. In CappedMode, Session
+	// blocks until a slot is available here; in LeakyMode it is
+	// consulted on a best-effort basis.
+	freeSlots chan int
 
 	// session holds the base session from which all sessions
 	// returned from Pool.Session will be copied.
@@ -39,6 +103,74 @@ type Pool struct {
 
 	// closed holds whether the Pool has been closed.
 	closed bool
+
+	// lastReset holds the time Reset was last called, or the zero
+	// time if it never has been.
+	lastReset time.Time
+
+	// url holds the dial URL used to redial session, if the pool
+	// owns its own dial (created via NewPoolWithURL). It is empty
+	// for pools created from an existing *mgo.Session.
+	url string
+
+	// reconnecting holds whether a reconnect loop is currently
+	// running, so that pinger and session0 failures don't start
+	// redundant reconnect attempts.
+	reconnecting bool
+
+	// reconnectWG is done when no reconnect loop is running, so that
+	// Close can wait for one to finish before tearing down sessions.
+	reconnectWG sync.WaitGroup
+
+	// closeOnce ensures the sessions and base session are torn down
+	// exactly once, even though Close may be called more than once
+	// and p.closed is now set well before that teardown happens (see
+	// Close).
+	closeOnce sync.Once
+
+	// statsMu guards stats.
+	statsMu sync.Mutex
+	stats   PoolStats
+}
+
+// PoolStats holds statistics and health information about a Pool.
+type PoolStats struct {
+	// Reconnecting reports whether the pool is currently attempting
+	// to redial its MongoDB cluster after a failure.
+	Reconnecting bool
+
+	// Attempts holds the number of redial attempts made by the
+	// current (or most recent) reconnect loop.
+	Attempts int
+
+	// LastError holds the error returned by the most recent redial
+	// attempt, or nil if the pool has never failed to redial.
+	LastError error
+
+	// LastPing holds the time of the most recent successful ping of
+	// the pool's base session.
+	LastPing time.Time
+
+	// LiveSlots holds the number of slots that currently hold a
+	// cached base session.
+	LiveSlots int
+
+	// TotalCopies holds the total number of Session.Copy calls made
+	// to populate slots and throwaway sessions over the pool's
+	// lifetime.
+	TotalCopies int64
+
+	// TotalClones holds the total number of Session.Clone calls
+	// handed out to callers over the pool's lifetime.
+	TotalClones int64
+
+	// PingFailures holds the total number of failed pings seen by
+	// the pinger or by Session, over the pool's lifetime.
+	PingFailures int64
+
+	// TimeSinceReset holds how long it has been since Reset was
+	// last called, or zero if it never has been.
+	TimeSinceReset time.Duration
 }
 
 // Logger is used by mgosession to log information about sessions.
@@ -49,20 +181,87 @@ type Logger interface {
 	Info(message string)
 }
 
+// SessionHandle is returned from Pool.Session and must be passed to
+// Pool.Release once the caller is done with the session. It carries
+// enough information for Release to run in O(1) time.
+type SessionHandle struct {
+	// Session is the mgo session the caller should use. It must not
+	// be used after Release has been called.
+	Session *mgo.Session
+
+	pool *Pool
+	// slot holds the index into Pool.sessions that this handle was
+	// acquired from, or -1 if the session is a throwaway session
+	// created because the pool was exhausted in LeakyMode.
+	slot int
+	// base holds the *mgo.Session that p.sessions[slot] held when
+	// Session was cloned, so DoNotReuse can tell whether that slot
+	// still holds the same base session before evicting it.
+	base *mgo.Session
+}
+
 // NewPool returns a session pool that maintains a maximum
-// of maxSessions sessions available for reuse.
+// of maxSessions sessions available for reuse, operating in LeakyMode.
 //
-// All the sessions will be coped (with Session.Copy) from s.
+// All the sessions will be copied (with Session.Copy) from s.
 //
 // The logger is used to log informational messages about the pool
 // and may be nil if no logging is required.
 func NewPool(logger Logger, s *mgo.Session, maxSessions int) *Pool {
+	return NewPoolWithOptions(logger, s, PoolOptions{
+		MaxSessions: maxSessions,
+		Mode:        LeakyMode,
+	})
+}
+
+// NewPoolWithURL dials url itself and returns a pool backed by that
+// dial. Unlike NewPool, the returned Pool owns its connection: if the
+// pinger detects a dead base session, or Session finds it unusable,
+// the pool transparently redials url with exponential backoff
+// (configured via opts) instead of leaving callers stuck with dead
+// clones until something external redials. Reconnect progress can be
+// observed via Pool.Stats.
+func NewPoolWithURL(logger Logger, url string, opts PoolOptions) (*Pool, error) {
+	s, err := mgo.Dial(url)
+	if err != nil {
+		return nil, fmt.Errorf("cannot dial %q: %v", url, err)
+	}
+	defer s.Close()
+	p := newPool(logger, s, opts, url)
+	p.statsMu.Lock()
+	p.stats.LastPing = Clock.Now()
+	p.statsMu.Unlock()
+	return p, nil
+}
+
+// NewPoolWithOptions is like NewPool but allows the acquisition mode
+// and other options to be configured via opts.
+func NewPoolWithOptions(logger Logger, s *mgo.Session, opts PoolOptions) *Pool {
+	return newPool(logger, s, opts, "")
+}
+
+// newPool is the shared Pool constructor. url must be set before the
+// pinger goroutine is started below, since the pinger (and the
+// reconnectLoop/handleFailure it can trigger) reads p.url with no
+// synchronization of its own; setting p.url on the returned Pool after
+// the fact, as NewPoolWithURL used to, would be a data race.
+func newPool(logger Logger, s *mgo.Session, opts PoolOptions, url string) *Pool {
 	if logger == nil {
 		logger = nullLogger{}
 	}
+	if opts.MaxSessions <= 0 {
+		opts.MaxSessions = 1
+	}
+	freeSlots := make(chan int, opts.MaxSessions)
+	for i := 0; i < opts.MaxSessions; i++ {
+		freeSlots <- i
+	}
 	p := &Pool{
-		sessions: make([]*mgo.Session, maxSessions),
-		session:  s.Copy(),
+		opts:      opts,
+		sessions:  make([]*mgo.Session, opts.MaxSessions),
+		freeSlots: freeSlots,
+		session:   s.Copy(),
+		url:       url,
 	}
 	p.tomb.Go(func() error {
 		return p.pinger(logger)
@@ -72,6 +271,7 @@ func NewPool(logger Logger, s *mgo.Session, maxSessions int) *Pool {
 
 // pinger occasionally pings the sessions in the pool
 // to make sure that they are OK, and resets the pool
+// (or, for pools created with NewPoolWithURL, redials it)
 // if it gets an error. This means that even if nothing
 // external notices an error and calls Reset, our maximum
 // window for an outage is pingInterval.
@@ -86,66 +286,211 @@ func (p *Pool) pinger(logger Logger) error {
 			return nil
 		case <-Clock.After(pingInterval):
 		}
-		session := p.Session(logger)
-		if session.Ping() != nil {
-			p.Reset()
+		if p.pingBase() != nil {
+			p.statsMu.Lock()
+			p.stats.PingFailures++
+			p.statsMu.Unlock()
+			p.handleFailure(logger)
+		} else {
+			p.statsMu.Lock()
+			p.stats.LastPing = Clock.Now()
+			p.statsMu.Unlock()
 		}
-		session.Close()
 	}
 }
 
-// Session returns a new session from the pool. It may
-// reuse an existing session that has not been marked
-// with DoNotReuse.
+// pingBase pings a throwaway clone of the pool's base session. It
+// never goes through freeSlots, so it cannot be blocked by a
+// CappedMode pool that is fully on loan to callers; session0 would
+// be, since it competes for the same bounded semaphore, which would
+// stop the pinger (and the reconnect logic that depends on it) from
+// ever detecting a dead session under load.
+func (p *Pool) pingBase() error {
+	p.mu.Lock()
+	s := p.session.Clone()
+	p.mu.Unlock()
+	defer s.Close()
+	return s.Ping()
+}
+
+// Session returns a new session handle from the pool. In LeakyMode it
+// never blocks: it reuses an existing session that has not been marked
+// with DoNotReuse, or creates a throwaway one if the pool is exhausted.
+// In CappedMode it blocks until a session is returned to the pool via
+// Release, guaranteeing at most opts.MaxSessions sessions on loan at
+// once.
 //
 // The logger is used to log requests associated with
 // the session request and may be nil if no logging is required.
 //
+// The returned handle must be passed to Release once the caller
+// is done with it.
+//
 // Session may be called concurrently.
-func (p *Pool) Session(logger Logger) *mgo.Session {
+func (p *Pool) Session(logger Logger) *SessionHandle {
+	return p.session0(logger)
+}
+
+func (p *Pool) session0(logger Logger) *SessionHandle {
 	if logger == nil {
 		logger = nullLogger{}
 	}
+	switch p.opts.Mode {
+	case CappedMode:
+		select {
+		case slot := <-p.freeSlots:
+			return p.acquireSlot(logger, slot)
+		case <-p.tomb.Dying():
+			panic("Session called on closed Pool")
+		}
+	default:
+		select {
+		case slot := <-p.freeSlots:
+			return p.acquireSlot(logger, slot)
+		default:
+			logger.Info("pool exhausted; creating throwaway session")
+			p.mu.Lock()
+			if p.closed {
+				p.mu.Unlock()
+				panic("Session called on closed Pool")
+			}
+			base := p.session
+			p.mu.Unlock()
+			s := base.Copy()
+			if p.opts.Configure != nil {
+				p.opts.Configure(s)
+			}
+			p.statsMu.Lock()
+			p.stats.TotalCopies++
+			p.statsMu.Unlock()
+			return &SessionHandle{
+				Session: s,
+				pool:    p,
+				slot:    -1,
+			}
+		}
+	}
+}
+
+// acquireSlot returns a handle for the session stored at slot,
+// creating it first if necessary.
+func (p *Pool) acquireSlot(logger Logger, slot int) *SessionHandle {
 	p.mu.Lock()
-	defer p.mu.Unlock()
 	if p.closed {
+		p.mu.Unlock()
 		panic("Session called on closed Pool")
 	}
-	s := p.sessions[p.sessionIndex]
+	s := p.sessions[slot]
+	var pingErr error
 	if s == nil {
-		logger.Info(fmt.Sprintf("creating new session; index %d", p.sessionIndex))
+		logger.Info(fmt.Sprintf("creating new session; index %d", slot))
 		s = p.session.Copy()
+		if p.opts.Configure != nil {
+			p.opts.Configure(s)
+		}
 		// Ping the session so that we're sure that the returned session
 		// is attached to a mongodb socket otherwise we won't
 		// be limiting the number of sessions at all.
-		// Ignore the error because we'll do the same whether there's
-		// an error or not.
-		s.Ping()
-		p.sessions[p.sessionIndex] = s
+		pingErr = s.Ping()
+		p.sessions[slot] = s
+		p.statsMu.Lock()
+		p.stats.TotalCopies++
+		p.statsMu.Unlock()
 	} else {
-		logger.Debug(fmt.Sprintf("reusing session; index %d", p.sessionIndex))
+		logger.Debug(fmt.Sprintf("reusing session; index %d", slot))
+	}
+	h := &SessionHandle{
+		Session: s.Clone(),
+		pool:    p,
+		slot:    slot,
+		base:    s,
+	}
+	p.mu.Unlock()
+	p.statsMu.Lock()
+	p.stats.TotalClones++
+	p.statsMu.Unlock()
+	if pingErr != nil {
+		p.statsMu.Lock()
+		p.stats.PingFailures++
+		p.statsMu.Unlock()
+		// The base session is unusable; kick off a reconnect rather
+		// than silently handing out clones of a dead session.
+		p.handleFailure(logger)
 	}
-	p.sessionIndex = (p.sessionIndex + 1) % len(p.sessions)
-	return s.Clone()
+	return h
+}
+
+// Release returns a session handle to the pool, making its slot
+// available to the next caller of Session. Throwaway sessions created
+// when the pool was exhausted in LeakyMode are closed instead.
+//
+// Release is O(1) and may be called concurrently.
+func (p *Pool) Release(h *SessionHandle) {
+	h.Session.Close()
+	if h.slot == -1 {
+		// Throwaway session; nothing to return to the pool.
+		return
+	}
+	p.freeSlots <- h.slot
 }
 
 // Close closes the pool. It may be called concurrently with other
 // Pool methods, but once called, a call to Session will panic.
 func (p *Pool) Close() {
-	// First stop the pinger so that it won't
-	// ask for any sessions after we're closed.
+	// Mark the pool closed before anything else, so that a reconnect
+	// loop racing with us notices as soon as it next checks p.closed
+	// (at the top of its loop, or right after a dial completes)
+	// instead of only once we get around to it below. Without this,
+	// a dial that completes while Close is still waiting on
+	// reconnectWG would see p.closed still false and swap in a new
+	// session moments before we tear the pool down anyway.
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	// Stop the pinger so that it won't ask for any sessions now that
+	// we're closed.
 	p.tomb.Kill(nil)
 	p.tomb.Wait()
 
-	// Then close everything down.
+	// Wait for any in-flight reconnect loop to notice and finish
+	// before we tear down the sessions it might be about to replace.
+	p.reconnectWG.Wait()
+
+	// Then close everything down, exactly once.
+	p.closeOnce.Do(func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		p.closeSessions()
+		p.session.Close()
+	})
+}
+
+// Stats returns a snapshot of the pool's health and usage: its
+// reconnection state, how many slots are currently populated, how
+// many copies and clones it has created over its lifetime, how many
+// pings have failed, and how long it has been since Reset was last
+// called. It is safe to call Stats concurrently, including from an
+// expvar.Func registered with PublishMetrics.
+func (p *Pool) Stats() PoolStats {
 	p.mu.Lock()
-	defer p.mu.Unlock()
-	if p.closed {
-		return
+	live := 0
+	for _, s := range p.sessions {
+		if s != nil {
+			live++
+		}
 	}
-	p.closed = true
-	p.closeSessions()
-	p.session.Close()
+	lastReset := p.lastReset
+	p.mu.Unlock()
+
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	stats := p.stats
+	stats.LiveSlots = live
+	if !lastReset.IsZero() {
+		stats.TimeSinceReset = Clock.Now().Sub(lastReset)
+	}
+	return stats
 }
 
 // Reset resets the session pool so that no existing
@@ -156,6 +501,7 @@ func (p *Pool) Reset() {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 	p.closeSessions()
+	p.lastReset = Clock.Now()
 }
 
 func (p *Pool) closeSessions() {
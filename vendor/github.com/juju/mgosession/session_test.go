@@ -0,0 +1,100 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/juju/mgosession"
+	"github.com/juju/mgosession/mgosessiontest"
+)
+
+func TestLeakyModeReturnsThrowawaySessionWhenExhausted(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{MaxSessions: 1})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+	pool := srv.Pool()
+
+	h1 := pool.Session(nil)
+	defer pool.Release(h1)
+
+	before := pool.Stats().TotalCopies
+
+	done := make(chan *mgosession.SessionHandle, 1)
+	go func() {
+		done <- pool.Session(nil)
+	}()
+
+	var h2 *mgosession.SessionHandle
+	select {
+	case h2 = <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("LeakyMode blocked with its only slot on loan")
+	}
+	defer pool.Release(h2)
+
+	if after := pool.Stats().TotalCopies; after != before+1 {
+		t.Fatalf("expected a throwaway copy to be created, TotalCopies %d -> %d", before, after)
+	}
+}
+
+func TestLeakyModeReusesReleasedSlot(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{MaxSessions: 1})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+	pool := srv.Pool()
+
+	h1 := pool.Session(nil)
+	pool.Release(h1)
+
+	before := pool.Stats().TotalCopies
+	h2 := pool.Session(nil)
+	pool.Release(h2)
+	if after := pool.Stats().TotalCopies; after != before {
+		t.Fatalf("expected no new copy when reusing a released slot, got %d -> %d", before, after)
+	}
+}
+
+func TestCappedModeBlocksUntilRelease(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+
+	base := srv.Pool().Session(nil)
+	defer srv.Pool().Release(base)
+
+	pool := mgosession.NewPoolWithOptions(nil, base.Session, mgosession.PoolOptions{
+		MaxSessions: 1,
+		Mode:        mgosession.CappedMode,
+	})
+	defer pool.Close()
+
+	h1 := pool.Session(nil)
+
+	acquired := make(chan *mgosession.SessionHandle, 1)
+	go func() {
+		acquired <- pool.Session(nil)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatalf("CappedMode returned a session before the only slot was released")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	pool.Release(h1)
+
+	select {
+	case h2 := <-acquired:
+		pool.Release(h2)
+	case <-time.After(time.Second):
+		t.Fatalf("CappedMode did not unblock after Release")
+	}
+}
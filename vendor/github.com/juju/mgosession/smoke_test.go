@@ -0,0 +1,38 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession_test
+
+import (
+	"testing"
+
+	"github.com/juju/mgosession"
+)
+
+// TestRegistryLifecycleWithoutDialing exercises the parts of Registry
+// that don't require a real MongoDB to dial, so it actually runs (and
+// asserts something) in environments with no mongod on PATH, unlike
+// the tests outside this file, which need mgosessiontest.New to
+// succeed and otherwise just skip.
+func TestRegistryLifecycleWithoutDialing(t *testing.T) {
+	reg, err := mgosession.NewRegistry(nil, nil)
+	if err != nil {
+		t.Fatalf("NewRegistry: %v", err)
+	}
+	if p := reg.Pool("missing"); p != nil {
+		t.Fatalf("expected a nil Pool for an unregistered name, got %v", p)
+	}
+	// Close must be safe to call, including more than once, even
+	// when nothing was ever registered.
+	reg.Close()
+	reg.Close()
+}
+
+// TestAcquireModeZeroValueIsLeaky locks in that the zero value of
+// AcquireMode (and so a PoolOptions{} with Mode left unset) is
+// LeakyMode, per its doc comment.
+func TestAcquireModeZeroValueIsLeaky(t *testing.T) {
+	var mode mgosession.AcquireMode
+	if mode != mgosession.LeakyMode {
+		t.Fatalf("expected the zero AcquireMode to be LeakyMode, got %v", mode)
+	}
+}
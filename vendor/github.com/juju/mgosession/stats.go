@@ -0,0 +1,45 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession
+
+import (
+	"expvar"
+)
+
+// DoNotReuse marks the session handle h as unfit for reuse: the
+// cached base session it was cloned from is evicted and closed, so
+// that the next Session call for that slot re-copies from the pool's
+// current base session. It also releases h's slot back to the pool,
+// so it should be called instead of Release, not in addition to it.
+//
+// This lets a request handler that saw a transient error on its
+// session say "don't propagate this to the next request" without
+// forcing a Reset of the whole pool.
+func (p *Pool) DoNotReuse(h *SessionHandle) {
+	h.Session.Close()
+	if h.slot == -1 {
+		// Throwaway session; there's nothing cached to evict.
+		return
+	}
+	p.mu.Lock()
+	// Only evict if the slot still holds the same base session h was
+	// cloned from: a Reset, a reconnect swap, or a concurrent
+	// DoNotReuse may already have replaced it, and closing that
+	// newer, healthy session would be wrong.
+	if s := p.sessions[h.slot]; s != nil && s == h.base {
+		s.Close()
+		p.sessions[h.slot] = nil
+	}
+	p.mu.Unlock()
+	p.freeSlots <- h.slot
+}
+
+// PublishMetrics publishes the pool's Stats under name via expvar, so
+// that it shows up alongside a process's other metrics. It panics if
+// name is already in use, per expvar.Publish, so it should be called
+// at most once per pool.
+func (p *Pool) PublishMetrics(name string) {
+	expvar.Publish(name, expvar.Func(func() interface{} {
+		return p.Stats()
+	}))
+}
@@ -0,0 +1,80 @@
+// Copyright 2016 Canonical Ltd.
+
+package mgosession_test
+
+import (
+	"testing"
+
+	"github.com/juju/mgosession/mgosessiontest"
+)
+
+func TestStatsReportsLiveSlotsCopiesAndClones(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{MaxSessions: 2})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+	pool := srv.Pool()
+
+	h1 := pool.Session(nil)
+	defer pool.Release(h1)
+	h2 := pool.Session(nil)
+	defer pool.Release(h2)
+
+	stats := pool.Stats()
+	if stats.LiveSlots != 2 {
+		t.Fatalf("expected 2 live slots, got %d", stats.LiveSlots)
+	}
+	if stats.TotalCopies != 2 {
+		t.Fatalf("expected 2 copies, got %d", stats.TotalCopies)
+	}
+	if stats.TotalClones != 2 {
+		t.Fatalf("expected 2 clones, got %d", stats.TotalClones)
+	}
+}
+
+func TestDoNotReuseForcesAFreshCopyOnNextSession(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{MaxSessions: 1})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+	pool := srv.Pool()
+
+	h1 := pool.Session(nil)
+	before := pool.Stats().TotalCopies
+	pool.DoNotReuse(h1)
+
+	h2 := pool.Session(nil)
+	defer pool.Release(h2)
+	if after := pool.Stats().TotalCopies; after != before+1 {
+		t.Fatalf("expected DoNotReuse to force a fresh copy, got %d -> %d", before, after)
+	}
+}
+
+// TestDoNotReuseIgnoresASlotAlreadyInvalidatedElsewhere guards against
+// DoNotReuse evicting whatever currently lives in its slot without
+// checking it is still the same session the handle was cloned from.
+// Reset (and the reconnect swap in reconnect.go, which shares the
+// same closeSessions helper) can invalidate a slot out from under a
+// handle that is still on loan; a stale DoNotReuse call for that
+// handle must leave the pool in a usable state rather than panicking
+// or corrupting accounting.
+func TestDoNotReuseIgnoresASlotAlreadyInvalidatedElsewhere(t *testing.T) {
+	srv, err := mgosessiontest.New(nil, mgosessiontest.Params{MaxSessions: 1})
+	if err != nil {
+		t.Skipf("cannot start ephemeral mongod: %v", err)
+	}
+	defer srv.Stop()
+	pool := srv.Pool()
+
+	h1 := pool.Session(nil)
+	pool.Reset()
+	pool.DoNotReuse(h1)
+
+	h2 := pool.Session(nil)
+	defer pool.Release(h2)
+	if stats := pool.Stats(); stats.LiveSlots != 1 {
+		t.Fatalf("expected the slot to be usable again after DoNotReuse, got %d live slots", stats.LiveSlots)
+	}
+}